@@ -0,0 +1,68 @@
+// Package acme provides an ACME/Let's Encrypt certificate manager for use
+// with spice.WithTLSConfig, mirroring the shape of
+// golang.org/x/crypto/acme/autocert.Manager.
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// AutocertManager obtains and renews TLS certificates from an ACME CA on
+// demand, driven by SNI, and serves the http-01 challenge handler needed
+// to prove domain ownership.
+type AutocertManager struct {
+	manager *autocert.Manager
+}
+
+// NewAutocertManager creates an AutocertManager that caches issued
+// certificates under cacheDir, registers email with the CA, and only
+// issues certificates for hosts accepted by policy.
+func NewAutocertManager(cacheDir string, email string, policy autocert.HostPolicy) *AutocertManager {
+	return &AutocertManager{
+		manager: &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cacheDir),
+			HostPolicy: policy,
+			Email:      email,
+		},
+	}
+}
+
+// TLSConfig returns a *tls.Config whose GetCertificate fetches (and if
+// necessary requests) a certificate for the negotiated SNI server name.
+// Pass the result to spice.WithTLSConfig.
+func (m *AutocertManager) TLSConfig() *tls.Config {
+	return m.manager.TLSConfig()
+}
+
+// HTTPHandler returns the http-01 challenge handler that must be served
+// on port 80 (or behind a redirect to it) for certificate issuance to
+// succeed. fallback is invoked for any request that isn't part of the
+// ACME challenge flow, and may be nil.
+func (m *AutocertManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return m.manager.HTTPHandler(fallback)
+}
+
+// ListenAndServeChallenge serves the http-01 challenge handler on addr
+// until ctx is cancelled.
+func (m *AutocertManager) ListenAndServeChallenge(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: m.HTTPHandler(nil),
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package spice
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/jsimonetti/go-spice/red"
+)
+
+// serveFakeAgent answers one agentOpPublic and any number of
+// agentOpDecrypt requests on a unix socket, returning plaintext for every
+// decrypt regardless of the ciphertext, so tests can focus on framing.
+func serveFakeAgent(t *testing.T, key *rsa.PrivateKey, plaintext []byte) string {
+	t.Helper()
+
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	var pubkey [red.TicketPubkeyBytes]byte
+	copy(pubkey[:], pub)
+
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				op := make([]byte, 1)
+				if _, err := conn.Read(op); err != nil {
+					return
+				}
+
+				switch op[0] {
+				case agentOpPublic:
+					conn.Write(pubkey[:])
+				case agentOpDecrypt:
+					length := make([]byte, 4)
+					if _, err := conn.Read(length); err != nil {
+						return
+					}
+					ciphertext := make([]byte, binary.BigEndian.Uint32(length))
+					if _, err := readAll(conn, ciphertext); err != nil {
+						return
+					}
+
+					respLen := make([]byte, 4)
+					binary.BigEndian.PutUint32(respLen, uint32(len(plaintext)))
+					conn.Write(respLen)
+					conn.Write(plaintext)
+				}
+			}()
+		}
+	}()
+
+	return path
+}
+
+func readAll(conn net.Conn, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		m, err := conn.Read(b[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func TestAgentTicketSignerRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	want := []byte("agent round trip")
+	path := serveFakeAgent(t, key, want)
+
+	signer, err := NewAgentTicketSigner(path)
+	if err != nil {
+		t.Fatalf("NewAgentTicketSigner: %v", err)
+	}
+
+	got, err := signer.Decrypt([]byte("ciphertext does not matter to the fake agent"))
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("Decrypt = %q, want %q", got, want)
+	}
+}
+
+func TestAgentTicketSignerDecryptRejectsOversizedResponse(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	oversized := make([]byte, maxAgentDecryptResponse+1)
+	path := serveFakeAgent(t, key, oversized)
+
+	signer, err := NewAgentTicketSigner(path)
+	if err != nil {
+		t.Fatalf("NewAgentTicketSigner: %v", err)
+	}
+
+	if _, err := signer.Decrypt([]byte("ciphertext")); err != errAgentResponseTooLarge {
+		t.Fatalf("Decrypt error = %v, want %v", err, errAgentResponseTooLarge)
+	}
+}
@@ -0,0 +1,103 @@
+package spice
+
+import (
+	"net"
+
+	"github.com/jsimonetti/go-spice/red"
+)
+
+// AuthContext carries the per-connection state an Authenticator needs to
+// validate a tenant's ticket, carry out a multi-step exchange, and pick
+// the compute destination. The tenant's encrypted ticket is decrypted via
+// Signer rather than a raw RSA key, so the private key can live outside
+// proxy memory.
+type AuthContext struct {
+	tenant  net.Conn
+	signer  TicketSigner
+	otp     string
+	address string
+
+	// Response holds the tenant's reply to the most recent AuthChallenge,
+	// populated by clientAuthMethod before the next call to Next. It is
+	// nil on the first call of an exchange.
+	Response []byte
+
+	// State is scratch space for multi-step Authenticators (e.g. the
+	// sasl package) to carry state between successive calls to Next
+	// within the same exchange. It is untouched by tenantHandshake.
+	State interface{}
+}
+
+// Tenant returns the tenant connection, e.g. for reading out-of-band data
+// an Authenticator needs beyond Response.
+func (c *AuthContext) Tenant() net.Conn { return c.tenant }
+
+// Signer decrypts the RSA ticket a tenant encrypted against the public
+// key advertised in the ServerLinkMessage.
+func (c *AuthContext) Signer() TicketSigner { return c.signer }
+
+// OTP returns the one-time password generated for this session.
+func (c *AuthContext) OTP() string { return c.otp }
+
+// Destination returns the compute address resolved so far, if any.
+func (c *AuthContext) Destination() string { return c.address }
+
+// Result is the outcome of a single step of an Authenticator exchange. It
+// is a sum type implemented by AuthDone, AuthChallenge and AuthDeny.
+type Result interface {
+	isResult()
+}
+
+// AuthDone terminates the exchange successfully, directing the session to
+// Destination. If Data is non-nil, it is written to the tenant as a final
+// challenge frame before the ticket result is sent — SCRAM-SHA-256 needs
+// this to deliver its server-final "v=<ServerSignature>" message, which a
+// compliant client waits for to authenticate the server.
+type AuthDone struct {
+	Destination string
+	Data        []byte
+}
+
+func (AuthDone) isResult() {}
+
+// AuthChallenge continues the exchange: Data is written to the tenant as
+// a challenge frame, and the tenant's reply is made available as
+// AuthContext.Response on the next call to Next.
+type AuthChallenge struct {
+	Data []byte
+}
+
+func (AuthChallenge) isResult() {}
+
+// AuthDeny terminates the exchange with a failure, reported to the tenant
+// as Code.
+type AuthDeny struct {
+	Code red.ErrorCode
+}
+
+func (AuthDeny) isResult() {}
+
+// Authenticator validates a tenant's authentication ticket, stepping
+// through as many challenge/response rounds as it needs (for SASL-style
+// mechanisms such as SCRAM) before returning a terminal Result.
+type Authenticator interface {
+	Next(ctx *AuthContext) (Result, error)
+}
+
+// CapabilityProvider is implemented by Authenticators that require
+// additional SPICE common capability bits (e.g.
+// SPICE_COMMON_CAP_AUTH_SASL) to be advertised in the ServerLinkMessage
+// offered to tenants.
+type CapabilityProvider interface {
+	CommonCapabilities() uint32
+}
+
+// Common capability bits advertised in ServerLinkMessage.CommonCapabilities,
+// matching the bit positions red.Capability defines (AuthSelection=0,
+// AuthSpice=1, AuthSASL=2, MiniHeader=3).
+const (
+	CapAuthSelection uint32 = 1 << 0
+	CapAuthSpice     uint32 = 1 << 1
+	CapAuthSASL      uint32 = 1 << 2
+	CapMiniHeader    uint32 = 1 << 3
+)
@@ -0,0 +1,38 @@
+package spice
+
+import (
+	"net"
+
+	"github.com/jsimonetti/go-spice/red"
+)
+
+// computeHandshake dials the resolved compute destination for a tenant
+// connection. It only opens the TCP connection: no SPICE link message is
+// sent to or read from the compute host.
+type computeHandshake struct {
+	proxy *Proxy
+
+	done bool
+
+	channelID   uint8
+	channelType red.ChannelType
+	sessionID   uint32
+
+	tenant  net.Conn
+	compute net.Conn
+}
+
+func (c *computeHandshake) Done() bool {
+	return c.done
+}
+
+func (c *computeHandshake) clientLinkStage(destination string) error {
+	conn, err := net.Dial("tcp", destination)
+	if err != nil {
+		c.proxy.collector.ComputeConnectFailure(destination, err)
+		return err
+	}
+	c.compute = conn
+	c.done = true
+	return nil
+}
@@ -0,0 +1,17 @@
+package spice
+
+import "errors"
+
+var errSessionNotFound = errors.New("spice: session not found")
+var errTLSNotConfigured = errors.New("spice: TLS not configured, use WithTLSConfig")
+var errInvalidSignerFile = errors.New("spice: invalid PEM-encoded RSA private key")
+var errAgentResponseTooLarge = errors.New("spice: agent decrypt response exceeds maxAgentDecryptResponse")
+
+// ErrLinkHeaderTooLarge is returned when a tenant's LinkHeader.Size
+// exceeds the proxy's configured MaxLinkMessageSize.
+var ErrLinkHeaderTooLarge = errors.New("spice: link message exceeds MaxLinkMessageSize")
+
+// ErrHandshakeTimeout is returned when a tenant's link and auth handshake
+// does not complete within the proxy's configured handshake timeout. The
+// compute side is not bounded by that timeout.
+var ErrHandshakeTimeout = errors.New("spice: handshake timeout")
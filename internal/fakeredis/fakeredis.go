@@ -0,0 +1,182 @@
+// Package fakeredis runs a minimal in-process RESP2 server implementing
+// just the commands go-redis issues for Set/Get/Exists/Del, so tests
+// across this module don't require a real Redis instance.
+package fakeredis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Start runs the fake server on a loopback port, closing it when t's
+// test completes, and returns its address.
+func Start(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	store := &store{values: make(map[string]string)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go store.serve(conn)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+type store struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (s *store) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			if len(args) < 3 {
+				fmt.Fprint(conn, "-ERR wrong number of arguments\r\n")
+				continue
+			}
+			s.mu.Lock()
+			s.values[args[1]] = args[2]
+			s.mu.Unlock()
+			fmt.Fprint(conn, "+OK\r\n")
+
+		case "GET":
+			if len(args) < 2 {
+				fmt.Fprint(conn, "-ERR wrong number of arguments\r\n")
+				continue
+			}
+			s.mu.Lock()
+			v, ok := s.values[args[1]]
+			s.mu.Unlock()
+			if !ok {
+				fmt.Fprint(conn, "$-1\r\n")
+				continue
+			}
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+
+		case "EXISTS":
+			if len(args) < 2 {
+				fmt.Fprint(conn, "-ERR wrong number of arguments\r\n")
+				continue
+			}
+			s.mu.Lock()
+			_, ok := s.values[args[1]]
+			s.mu.Unlock()
+			if ok {
+				fmt.Fprint(conn, ":1\r\n")
+			} else {
+				fmt.Fprint(conn, ":0\r\n")
+			}
+
+		case "DEL":
+			n := 0
+			s.mu.Lock()
+			for _, key := range args[1:] {
+				if _, ok := s.values[key]; ok {
+					delete(s.values, key)
+					n++
+				}
+			}
+			s.mu.Unlock()
+			fmt.Fprintf(conn, ":%d\r\n", n)
+
+		case "PING":
+			fmt.Fprint(conn, "+PONG\r\n")
+
+		case "HELLO":
+			writeHelloReply(conn)
+
+		default:
+			fmt.Fprint(conn, "+OK\r\n")
+		}
+	}
+}
+
+// writeHelloReply answers HELLO with the minimal RESP2 array reply
+// go-redis expects during connection setup (it parses this as a flat
+// field/value list regardless of the protocol version negotiated).
+func writeHelloReply(conn net.Conn) {
+	fields := []string{
+		"server", "redis",
+		"version", "7.4.0",
+		"proto", "2",
+		"id", "1",
+		"mode", "standalone",
+		"role", "master",
+	}
+	fmt.Fprintf(conn, "*%d\r\n", len(fields)+2)
+	for _, f := range fields {
+		fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(f), f)
+	}
+	fmt.Fprint(conn, "$7\r\nmodules\r\n*0\r\n")
+}
+
+// readRESPCommand reads one RESP2 array-of-bulk-strings request, the
+// only form go-redis sends for commands.
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("redis fake: expected array, got %q", line)
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		head, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		head = strings.TrimRight(head, "\r\n")
+		if len(head) == 0 || head[0] != '$' {
+			return nil, fmt.Errorf("redis fake: expected bulk string, got %q", head)
+		}
+		size, err := strconv.Atoi(head[1:])
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2) // trailing \r\n
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+
+	return args, nil
+}
@@ -0,0 +1,51 @@
+// Package metrics defines the observability hooks a spice.Proxy emits
+// across its handshake and session lifecycle, along with a Prometheus
+// Collector implementation and an OpenTelemetry tracing helper.
+package metrics
+
+import (
+	"time"
+
+	"github.com/jsimonetti/go-spice/red"
+)
+
+// HandshakeFinisher is returned by Collector.HandshakeStarted and must be
+// called exactly once, with the handshake's terminal error (nil on
+// success), when the handshake completes.
+type HandshakeFinisher func(err error)
+
+// Collector receives events from a proxy's handshake and session
+// lifecycle so that observability backends can be plugged in via
+// spice.WithCollector.
+type Collector interface {
+	// HandshakeStarted marks the beginning of a tenant handshake and
+	// returns a finisher to call once it completes.
+	HandshakeStarted() HandshakeFinisher
+
+	// AuthAttempt records the outcome and duration of one Authenticator
+	// exchange.
+	AuthAttempt(method red.AuthMethod, ok bool, dur time.Duration)
+
+	// ComputeConnectFailure records a failure to dial a compute
+	// destination.
+	ComputeConnectFailure(destination string, err error)
+
+	// SessionOpened/SessionClosed bracket a proxied channel's lifetime.
+	SessionOpened(channelType red.ChannelType, destination string)
+	SessionClosed(channelType red.ChannelType, destination string)
+
+	// BytesTransferred reports bytes copied in each direction for a
+	// proxied channel, typically on SessionClosed.
+	BytesTransferred(channelType red.ChannelType, tenantToCompute, computeToTenant int64)
+}
+
+// NopCollector discards every event. It is the default until
+// spice.WithCollector is used.
+type NopCollector struct{}
+
+func (NopCollector) HandshakeStarted() HandshakeFinisher             { return func(error) {} }
+func (NopCollector) AuthAttempt(red.AuthMethod, bool, time.Duration) {}
+func (NopCollector) ComputeConnectFailure(string, error)             {}
+func (NopCollector) SessionOpened(red.ChannelType, string)           {}
+func (NopCollector) SessionClosed(red.ChannelType, string)           {}
+func (NopCollector) BytesTransferred(red.ChannelType, int64, int64)  {}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/darkautism/go-spice")
+
+// StartHandshakeSpan starts a span covering one tenant handshake and the
+// session it opens. Call SetHandshakeAttributes once the session's
+// details are known, and End the returned span when the connection
+// closes.
+func StartHandshakeSpan(ctx context.Context) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "spice.handshake")
+}
+
+// SetHandshakeAttributes annotates span with the resolved session
+// details.
+func SetHandshakeAttributes(span trace.Span, sessionID uint32, channelType uint8, destination string) {
+	span.SetAttributes(
+		attribute.Int64("spice.session_id", int64(sessionID)),
+		attribute.Int64("spice.channel_type", int64(channelType)),
+		attribute.String("spice.destination", destination),
+	)
+}
+
+// RecordError marks span as failed with err, if err is non-nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jsimonetti/go-spice/red"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus is a Collector that records handshake and session activity
+// as Prometheus metrics.
+type Prometheus struct {
+	handshakeDuration    *prometheus.HistogramVec
+	authTotal            *prometheus.CounterVec
+	computeFailures      *prometheus.CounterVec
+	activeSessions       *prometheus.GaugeVec
+	bytesTenantToCompute *prometheus.CounterVec
+	bytesComputeToTenant *prometheus.CounterVec
+}
+
+// NewPrometheus creates a Prometheus Collector and registers its metrics
+// with reg.
+func NewPrometheus(reg prometheus.Registerer) (*Prometheus, error) {
+	p := &Prometheus{
+		handshakeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "spice",
+			Subsystem: "proxy",
+			Name:      "handshake_duration_seconds",
+			Help:      "Duration of tenant handshakes, by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"result"}),
+		authTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "spice",
+			Subsystem: "proxy",
+			Name:      "auth_attempts_total",
+			Help:      "Authentication attempts, partitioned by method and outcome.",
+		}, []string{"method", "result"}),
+		computeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "spice",
+			Subsystem: "proxy",
+			Name:      "compute_connect_failures_total",
+			Help:      "Failures to connect to a compute destination.",
+		}, []string{"destination"}),
+		activeSessions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "spice",
+			Subsystem: "proxy",
+			Name:      "active_sessions",
+			Help:      "Currently proxied sessions, by compute destination.",
+		}, []string{"destination"}),
+		bytesTenantToCompute: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "spice",
+			Subsystem: "proxy",
+			Name:      "bytes_tenant_to_compute_total",
+			Help:      "Bytes proxied from tenant to compute, by channel type.",
+		}, []string{"channel_type"}),
+		bytesComputeToTenant: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "spice",
+			Subsystem: "proxy",
+			Name:      "bytes_compute_to_tenant_total",
+			Help:      "Bytes proxied from compute to tenant, by channel type.",
+		}, []string{"channel_type"}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		p.handshakeDuration, p.authTotal, p.computeFailures,
+		p.activeSessions, p.bytesTenantToCompute, p.bytesComputeToTenant,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Prometheus) HandshakeStarted() HandshakeFinisher {
+	start := time.Now()
+	return func(err error) {
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		p.handshakeDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (p *Prometheus) AuthAttempt(method red.AuthMethod, ok bool, dur time.Duration) {
+	result := "ok"
+	if !ok {
+		result = "denied"
+	}
+	p.authTotal.WithLabelValues(method.String(), result).Inc()
+}
+
+func (p *Prometheus) ComputeConnectFailure(destination string, err error) {
+	p.computeFailures.WithLabelValues(destination).Inc()
+}
+
+func (p *Prometheus) SessionOpened(channelType red.ChannelType, destination string) {
+	p.activeSessions.WithLabelValues(destination).Inc()
+}
+
+func (p *Prometheus) SessionClosed(channelType red.ChannelType, destination string) {
+	p.activeSessions.WithLabelValues(destination).Dec()
+}
+
+func (p *Prometheus) BytesTransferred(channelType red.ChannelType, tenantToCompute, computeToTenant int64) {
+	label := strconv.Itoa(int(channelType))
+	p.bytesTenantToCompute.WithLabelValues(label).Add(float64(tenantToCompute))
+	p.bytesComputeToTenant.WithLabelValues(label).Add(float64(computeToTenant))
+}
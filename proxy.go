@@ -0,0 +1,284 @@
+package spice
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/darkautism/go-spice/metrics"
+	"github.com/jsimonetti/go-spice/red"
+	"github.com/sirupsen/logrus"
+)
+
+// Proxy accepts tenant connections, performs the SPICE handshake and
+// proxies the resulting stream to a compute destination.
+type Proxy struct {
+	log *logrus.Entry
+
+	authenticator map[red.AuthMethod]Authenticator
+	sessionTable  SessionTable
+	ticketSigner  TicketSigner
+
+	tlsConfig *tls.Config
+	vhosts    map[string]*vhost
+
+	handshakeTimeout   time.Duration
+	maxLinkMessageSize uint32
+
+	collector metrics.Collector
+
+	signerErr error
+}
+
+// Option configures a Proxy at construction time.
+type Option func(*Proxy)
+
+// vhost holds the authenticator and sessionTable a TLS tenant connection
+// is routed to based on the SNI server name it presented.
+type vhost struct {
+	authenticator map[red.AuthMethod]Authenticator
+	sessionTable  SessionTable
+}
+
+// NewProxy creates a Proxy with the given options applied. Unless
+// WithTicketSigner or WithSignerFile is used, a fresh in-memory RSA
+// ticket signer is generated.
+func NewProxy(opts ...Option) (*Proxy, error) {
+	p := &Proxy{
+		log:           logrus.NewEntry(logrus.StandardLogger()),
+		authenticator: make(map[red.AuthMethod]Authenticator),
+		sessionTable:  newMapSessionTable(),
+		vhosts:        make(map[string]*vhost),
+		collector:     metrics.NopCollector{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.signerErr != nil {
+		return nil, p.signerErr
+	}
+
+	if p.ticketSigner == nil {
+		signer, err := NewTicketSigner(0)
+		if err != nil {
+			return nil, err
+		}
+		p.ticketSigner = signer
+	}
+
+	return p, nil
+}
+
+// WithTicketSigner configures the TicketSigner used to decrypt tenant
+// authentication tickets, replacing the default in-memory RSA key.
+func WithTicketSigner(signer TicketSigner) Option {
+	return func(p *Proxy) {
+		p.ticketSigner = signer
+	}
+}
+
+// WithSignerFile configures the proxy to decrypt tenant authentication
+// tickets using the PEM-encoded RSA private key at path, loaded once at
+// startup. Errors loading the key surface from NewProxy.
+func WithSignerFile(path string) Option {
+	return func(p *Proxy) {
+		signer, err := NewTicketSignerFromFile(path)
+		if err != nil {
+			p.signerErr = err
+			return
+		}
+		p.ticketSigner = signer
+	}
+}
+
+// WithAuthenticator registers auth to handle tenant connections that
+// select method during the link handshake.
+func WithAuthenticator(method red.AuthMethod, auth Authenticator) Option {
+	return func(p *Proxy) {
+		p.authenticator[method] = auth
+	}
+}
+
+// WithSessionTable replaces the proxy's default in-process SessionTable
+// with table, typically a shared backend such as
+// spice/sessiontable/redis or spice/sessiontable/memcache so multiple
+// proxy replicas behind a load balancer see the same sessions.
+func WithSessionTable(table SessionTable) Option {
+	return func(p *Proxy) {
+		p.sessionTable = table
+	}
+}
+
+// WithHandshakeTimeout bounds how long a tenant connection has to
+// complete the link and auth handshake before it is closed with
+// ErrHandshakeTimeout. The zero value (the default) disables the
+// deadline.
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.handshakeTimeout = d
+	}
+}
+
+// WithMaxLinkMessageSize overrides the default 32 KiB cap on a single
+// SPICE link message body, guarding against memory exhaustion from a
+// hostile peer's LinkHeader.Size.
+func WithMaxLinkMessageSize(n uint32) Option {
+	return func(p *Proxy) {
+		p.maxLinkMessageSize = n
+	}
+}
+
+// WithCollector registers a metrics.Collector to receive handshake and
+// session lifecycle events, e.g. metrics.NewPrometheus's Collector.
+func WithCollector(collector metrics.Collector) Option {
+	return func(p *Proxy) {
+		p.collector = collector
+	}
+}
+
+// WithTLSConfig enables TLS for tenant connections requesting the
+// `?tls-port=` endpoint, using cfg to terminate the handshake. cfg is
+// typically produced by acme.AutocertManager.TLSConfig, but any
+// *tls.Config with a GetCertificate/Certificates set works.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(p *Proxy) {
+		p.tlsConfig = cfg
+	}
+}
+
+// WithVhost routes TLS tenant connections presenting the given SNI server
+// name to a distinct authenticator and session table, instead of the
+// proxy's defaults.
+func WithVhost(serverName string, authenticator map[red.AuthMethod]Authenticator, table SessionTable) Option {
+	return func(p *Proxy) {
+		p.vhosts[serverName] = &vhost{authenticator: authenticator, sessionTable: table}
+	}
+}
+
+// ListenAndServeTLS listens on addr and serves tenant connections with TLS
+// termination. WithTLSConfig must have been used to configure a
+// *tls.Config.
+func (p *Proxy) ListenAndServeTLS(addr string) error {
+	if p.tlsConfig == nil {
+		return errTLSNotConfigured
+	}
+
+	ln, err := tls.Listen("tcp", addr, p.tlsConfig)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go p.handle(conn)
+	}
+}
+
+// ListenAndServe listens on addr and serves tenant connections in
+// plaintext, without TLS termination. TLS is an additive mode enabled by
+// WithTLSConfig and ListenAndServeTLS; it is not required to run the
+// proxy.
+func (p *Proxy) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go p.handle(conn)
+	}
+}
+
+// handle runs the tenant handshake on an already-accepted connection and
+// keeps proxying the resulting stream until it is closed.
+func (p *Proxy) handle(tenant net.Conn) {
+	defer tenant.Close()
+
+	_, span := metrics.StartHandshakeSpan(context.Background())
+	defer span.End()
+
+	finishHandshake := p.collector.HandshakeStarted()
+
+	handshake := &tenantHandshake{proxy: p}
+	if tlsConn, ok := tenant.(*tls.Conn); ok {
+		if p.handshakeTimeout > 0 {
+			if err := tlsConn.SetReadDeadline(time.Now().Add(p.handshakeTimeout)); err != nil {
+				finishHandshake(err)
+				p.log.WithError(err).Error("tenant handshake failed")
+				return
+			}
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			finishHandshake(err)
+			metrics.RecordError(span, err)
+			p.log.WithError(err).Error("tls handshake failed")
+			return
+		}
+		if p.handshakeTimeout > 0 {
+			if err := tlsConn.SetReadDeadline(time.Time{}); err != nil {
+				finishHandshake(err)
+				p.log.WithError(err).Error("tenant handshake failed")
+				return
+			}
+		}
+		if v, ok := p.vhosts[tlsConn.ConnectionState().ServerName]; ok {
+			handshake.authenticator = v.authenticator
+			handshake.sessionTable = v.sessionTable
+		}
+	}
+
+	compute, err := handshake.clientLinkStage(tenant)
+	finishHandshake(err)
+	metrics.RecordError(span, err)
+	if err != nil {
+		p.log.WithError(err).Error("tenant handshake failed")
+		return
+	}
+	defer compute.Close()
+
+	metrics.SetHandshakeAttributes(span, handshake.sessionID, uint8(handshake.channelType), handshake.destination)
+
+	p.collector.SessionOpened(handshake.channelType, handshake.destination)
+	defer p.collector.SessionClosed(handshake.channelType, handshake.destination)
+
+	tenantToCompute, computeToTenant := proxyStream(tenant, compute)
+	p.collector.BytesTransferred(handshake.channelType, tenantToCompute, computeToTenant)
+}
+
+// proxyStream copies data bidirectionally between the tenant and compute
+// connections until either side closes, returning the number of bytes
+// copied in each direction. Whichever direction finishes first closes
+// both connections, so the other direction's blocked read is released
+// promptly instead of leaking the goroutine.
+func proxyStream(tenant, compute net.Conn) (tenantToCompute, computeToTenant int64) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		tenantToCompute, _ = io.Copy(compute, tenant)
+		compute.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		computeToTenant, _ = io.Copy(tenant, compute)
+		tenant.Close()
+	}()
+
+	wg.Wait()
+	return tenantToCompute, computeToTenant
+}
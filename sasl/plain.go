@@ -0,0 +1,37 @@
+package sasl
+
+import (
+	"bytes"
+	"errors"
+)
+
+// AuthenticateFunc validates a PLAIN authcid/password pair and, on
+// success, resolves the compute destination the session should be
+// proxied to.
+type AuthenticateFunc func(authzid, authcid, password string) (destination string, ok bool)
+
+type plainMechanism struct {
+	authenticate AuthenticateFunc
+}
+
+// NewPlain returns a MechanismFactory implementing the SASL PLAIN
+// mechanism (RFC 4616), validating credentials with authenticate.
+func NewPlain(authenticate AuthenticateFunc) MechanismFactory {
+	return func() Mechanism {
+		return &plainMechanism{authenticate: authenticate}
+	}
+}
+
+func (m *plainMechanism) Step(response []byte) ([]byte, bool, string, error) {
+	parts := bytes.SplitN(response, []byte{0}, 3)
+	if len(parts) != 3 {
+		return nil, false, "", errors.New("sasl: malformed PLAIN response")
+	}
+
+	destination, ok := m.authenticate(string(parts[0]), string(parts[1]), string(parts[2]))
+	if !ok {
+		return nil, false, "", errors.New("sasl: PLAIN authentication failed")
+	}
+
+	return nil, true, destination, nil
+}
@@ -0,0 +1,46 @@
+package sasl
+
+import "testing"
+
+func TestPlainMechanismStep(t *testing.T) {
+	auth := func(authzid, authcid, password string) (string, bool) {
+		if authcid == "alice" && password == "hunter2" {
+			return "10.0.0.1:5900", true
+		}
+		return "", false
+	}
+	mech := NewPlain(auth)()
+
+	challenge, done, dest, err := mech.Step([]byte("\x00alice\x00hunter2"))
+	if err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if !done {
+		t.Fatal("expected PLAIN to complete in one step")
+	}
+	if challenge != nil {
+		t.Fatalf("challenge = %v, want nil", challenge)
+	}
+	if dest != "10.0.0.1:5900" {
+		t.Fatalf("destination = %q, want %q", dest, "10.0.0.1:5900")
+	}
+}
+
+func TestPlainMechanismWrongPassword(t *testing.T) {
+	auth := func(authzid, authcid, password string) (string, bool) {
+		return "", false
+	}
+	mech := NewPlain(auth)()
+
+	if _, _, _, err := mech.Step([]byte("\x00alice\x00wrong")); err == nil {
+		t.Fatal("expected error for rejected credentials")
+	}
+}
+
+func TestPlainMechanismMalformed(t *testing.T) {
+	mech := NewPlain(func(string, string, string) (string, bool) { return "", true })()
+
+	if _, _, _, err := mech.Step([]byte("no-null-bytes")); err == nil {
+		t.Fatal("expected error for malformed PLAIN response")
+	}
+}
@@ -0,0 +1,165 @@
+package sasl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// CredentialLookupFunc returns the SCRAM-SHA-256 salt and iteration count
+// for authcid, and the SaltedPassword derived from the user's password,
+// or ok=false if authcid is unknown.
+type CredentialLookupFunc func(authcid string) (salt []byte, iterations int, saltedPassword []byte, ok bool)
+
+// DestinationFunc resolves the compute destination for an
+// already-authenticated authcid.
+type DestinationFunc func(authcid string) string
+
+// NewScramSHA256 returns a MechanismFactory implementing the
+// SCRAM-SHA-256 mechanism (RFC 7677).
+func NewScramSHA256(lookup CredentialLookupFunc, destination DestinationFunc) MechanismFactory {
+	return func() Mechanism {
+		return &scramMechanism{lookup: lookup, destination: destination}
+	}
+}
+
+type scramStep int
+
+const (
+	scramStepClientFirst scramStep = iota
+	scramStepClientFinal
+	scramStepDone
+)
+
+type scramMechanism struct {
+	lookup      CredentialLookupFunc
+	destination DestinationFunc
+
+	step scramStep
+
+	authcid        string
+	clientNonce    string
+	serverNonce    string
+	saltedPassword []byte
+	clientFirstMsg string
+	serverFirstMsg string
+}
+
+func (m *scramMechanism) Step(response []byte) ([]byte, bool, string, error) {
+	switch m.step {
+	case scramStepClientFirst:
+		return m.clientFirst(response)
+	case scramStepClientFinal:
+		return m.clientFinal(response)
+	default:
+		return nil, false, "", errors.New("sasl: SCRAM exchange already complete")
+	}
+}
+
+func (m *scramMechanism) clientFirst(response []byte) ([]byte, bool, string, error) {
+	msg := string(response)
+	// "n,,n=<authcid>,r=<client-nonce>" (gs2-header, username, nonce).
+	parts := strings.SplitN(msg, ",", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "n=") {
+		return nil, false, "", errors.New("sasl: malformed SCRAM client-first message")
+	}
+
+	attrs := strings.SplitN(parts[2], ",r=", 2)
+	if len(attrs) != 2 {
+		return nil, false, "", errors.New("sasl: malformed SCRAM client-first message")
+	}
+	m.authcid = strings.TrimPrefix(attrs[0], "n=")
+	m.clientNonce = attrs[1]
+
+	salt, iterations, saltedPassword, ok := m.lookup(m.authcid)
+	if !ok {
+		return nil, false, "", fmt.Errorf("sasl: unknown user %q", m.authcid)
+	}
+	m.saltedPassword = saltedPassword
+
+	nonceSuffix, err := randomNonce()
+	if err != nil {
+		return nil, false, "", err
+	}
+	m.serverNonce = m.clientNonce + nonceSuffix
+	m.clientFirstMsg = parts[2]
+
+	m.serverFirstMsg = fmt.Sprintf("r=%s,s=%s,i=%d",
+		m.serverNonce, base64.StdEncoding.EncodeToString(salt), iterations)
+
+	m.step = scramStepClientFinal
+	return []byte(m.serverFirstMsg), false, "", nil
+}
+
+func (m *scramMechanism) clientFinal(response []byte) ([]byte, bool, string, error) {
+	msg := string(response)
+	attrs := map[string]string{}
+	for _, kv := range strings.Split(msg, ",") {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			attrs[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	if attrs["r"] != m.serverNonce {
+		return nil, false, "", errors.New("sasl: SCRAM nonce mismatch")
+	}
+
+	channelBinding := attrs["c"]
+	proof, err := base64.StdEncoding.DecodeString(attrs["p"])
+	if err != nil {
+		return nil, false, "", errors.New("sasl: malformed SCRAM client proof")
+	}
+
+	authMessage := m.clientFirstMsg + "," + m.serverFirstMsg + ",c=" + channelBinding + ",r=" + m.serverNonce
+
+	clientKey := hmacSHA256(m.saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientSignature := hmacSHA256(storedKey[:], []byte(authMessage))
+
+	computedProof := xorBytes(clientKey, clientSignature)
+	if !hmac.Equal(proof, computedProof) {
+		return nil, false, "", errors.New("sasl: SCRAM authentication failed")
+	}
+
+	serverKey := hmacSHA256(m.saltedPassword, []byte("Server Key"))
+	serverSignature := hmacSHA256(serverKey, []byte(authMessage))
+
+	m.step = scramStepDone
+	finalMsg := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
+	return []byte(finalMsg), true, m.destination(m.authcid), nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func randomNonce() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(b), nil
+}
+
+// DeriveSaltedPassword computes the SaltedPassword a CredentialLookupFunc
+// should return for password, given salt and an iteration count, per RFC
+// 7677 (PBKDF2-HMAC-SHA256).
+func DeriveSaltedPassword(password string, salt []byte, iterations int) []byte {
+	return pbkdf2.Key([]byte(password), salt, iterations, sha256.Size, sha256.New)
+}
@@ -0,0 +1,121 @@
+package sasl
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// clientScramProof computes the RFC 7677 client proof and expected server
+// signature a compliant SCRAM-SHA-256 client would send/verify, given the
+// same SaltedPassword the server derived.
+func clientScramProof(saltedPassword []byte, authMessage string) (proof, serverSignature []byte) {
+	clientKey := hmacSHA256(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientSignature := hmacSHA256(storedKey[:], []byte(authMessage))
+	proof = xorBytes(clientKey, clientSignature)
+
+	serverKey := hmacSHA256(saltedPassword, []byte("Server Key"))
+	serverSignature = hmacSHA256(serverKey, []byte(authMessage))
+	return proof, serverSignature
+}
+
+func TestScramSHA256RoundTrip(t *testing.T) {
+	const authcid = "alice"
+	salt := []byte("NaCl")
+	iterations := 4096
+	saltedPassword := DeriveSaltedPassword("pencil", salt, iterations)
+
+	lookup := func(user string) ([]byte, int, []byte, bool) {
+		if user != authcid {
+			return nil, 0, nil, false
+		}
+		return salt, iterations, saltedPassword, true
+	}
+	destination := func(string) string { return "10.0.0.1:5900" }
+
+	mech := NewScramSHA256(lookup, destination)()
+
+	gs2Header := "n,,"
+	clientFirstBare := "n=" + authcid + ",r=clientnonce"
+
+	serverFirst, done, _, err := mech.Step([]byte(gs2Header + clientFirstBare))
+	if err != nil {
+		t.Fatalf("client-first Step: %v", err)
+	}
+	if done {
+		t.Fatal("expected SCRAM to require a second round")
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range strings.Split(string(serverFirst), ",") {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			attrs[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(gs2Header)) + ",r=" + attrs["r"]
+	authMessage := clientFirstBare + "," + string(serverFirst) + "," + clientFinalWithoutProof
+	proof, wantServerSignature := clientScramProof(saltedPassword, authMessage)
+
+	clientFinal := clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)
+
+	finalMsg, done, dest, err := mech.Step([]byte(clientFinal))
+	if err != nil {
+		t.Fatalf("client-final Step: %v", err)
+	}
+	if !done {
+		t.Fatal("expected SCRAM to complete after client-final")
+	}
+	if dest != "10.0.0.1:5900" {
+		t.Fatalf("destination = %q, want %q", dest, "10.0.0.1:5900")
+	}
+	if finalMsg == nil {
+		t.Fatal("expected a server-final frame to be delivered, got nil")
+	}
+
+	v, ok := strings.CutPrefix(string(finalMsg), "v=")
+	if !ok {
+		t.Fatalf("server-final message = %q, want v=... prefix", finalMsg)
+	}
+	gotServerSignature, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		t.Fatalf("decode server signature: %v", err)
+	}
+	if string(gotServerSignature) != string(wantServerSignature) {
+		t.Fatalf("server signature = %x, want %x", gotServerSignature, wantServerSignature)
+	}
+}
+
+func TestScramSHA256WrongProof(t *testing.T) {
+	salt := []byte("NaCl")
+	iterations := 4096
+	saltedPassword := DeriveSaltedPassword("pencil", salt, iterations)
+
+	lookup := func(user string) ([]byte, int, []byte, bool) {
+		return salt, iterations, saltedPassword, true
+	}
+	mech := NewScramSHA256(lookup, func(string) string { return "" })()
+
+	gs2Header := "n,,"
+	clientFirstBare := "n=alice,r=clientnonce"
+	serverFirst, _, _, err := mech.Step([]byte(gs2Header + clientFirstBare))
+	if err != nil {
+		t.Fatalf("client-first Step: %v", err)
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range strings.Split(string(serverFirst), ",") {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			attrs[kv[:i]] = kv[i+1:]
+		}
+	}
+	clientFinalWithoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte(gs2Header)) + ",r=" + attrs["r"]
+	badProof := make([]byte, 32)
+	clientFinal := clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(badProof)
+
+	if _, _, _, err := mech.Step([]byte(clientFinal)); err == nil {
+		t.Fatal("expected error for invalid client proof")
+	}
+}
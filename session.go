@@ -0,0 +1,73 @@
+package spice
+
+import "sync"
+
+// SessionTable tracks in-flight sessions so that the secondary channels
+// (cursor, inputs, display) of a tenant connection can be matched back to
+// the compute destination and one-time-password negotiated on the main
+// channel. The default implementation is an in-process map, which is
+// sufficient for a single proxy replica; WithSessionTable can substitute
+// a shared backend (see spice/sessiontable/redis and
+// spice/sessiontable/memcache) so that multiple replicas behind a load
+// balancer see the same sessions.
+type SessionTable interface {
+	Add(sessionID uint32, destination, otp string)
+	Lookup(sessionID uint32) bool
+	Connect(sessionID uint32) (string, error)
+	OTP(sessionID uint32) string
+	Delete(sessionID uint32)
+}
+
+type session struct {
+	destination string
+	otp         string
+}
+
+// mapSessionTable is the default, in-process SessionTable implementation.
+type mapSessionTable struct {
+	mu       sync.Mutex
+	sessions map[uint32]*session
+}
+
+func newMapSessionTable() *mapSessionTable {
+	return &mapSessionTable{sessions: make(map[uint32]*session)}
+}
+
+func (t *mapSessionTable) Add(sessionID uint32, destination, otp string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessions[sessionID] = &session{destination: destination, otp: otp}
+}
+
+func (t *mapSessionTable) Lookup(sessionID uint32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.sessions[sessionID]
+	return ok
+}
+
+func (t *mapSessionTable) Connect(sessionID uint32) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.sessions[sessionID]
+	if !ok {
+		return "", errSessionNotFound
+	}
+	return s.destination, nil
+}
+
+func (t *mapSessionTable) OTP(sessionID uint32) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.sessions[sessionID]
+	if !ok {
+		return ""
+	}
+	return s.otp
+}
+
+func (t *mapSessionTable) Delete(sessionID uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, sessionID)
+}
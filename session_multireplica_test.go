@@ -0,0 +1,141 @@
+package spice
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/darkautism/go-spice/internal/fakeredis"
+	"github.com/darkautism/go-spice/sessiontable/redis"
+)
+
+// echoComputeListener stands in for a compute host: it accepts
+// connections and echoes back whatever the proxy forwards, so the test
+// can prove bytes actually flow end to end through each replica's dial.
+func echoComputeListener(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// dialCompute drives computeHandshake in isolation, the same way
+// tenantHandshake.clientLinkStage does once a destination is known.
+func dialCompute(t *testing.T, proxy *Proxy, sessionID uint32, destination string) net.Conn {
+	t.Helper()
+
+	hs := &computeHandshake{proxy: proxy, sessionID: sessionID}
+	for !hs.Done() {
+		if err := hs.clientLinkStage(destination); err != nil {
+			t.Fatalf("compute clientLinkStage: %v", err)
+		}
+	}
+	return hs.compute
+}
+
+// TestMultiReplicaSessionReconnect reproduces the scenario that motivates
+// a shared SessionTable backend: a tenant's main channel completes on
+// one proxy replica, and a secondary channel (cursor, inputs, display)
+// reconnecting with the same sessionID lands on a different replica
+// behind a load balancer. With a shared backend (here, two independent
+// redis.SessionTable handles against one Redis instance), the second
+// replica must resolve the same destination and OTP the first recorded,
+// and complete its own proxied stream to the compute host.
+//
+// This drives computeHandshake and SessionTable directly rather than a
+// real tenant connection, so it proves session-table sharing and the
+// resulting byte-for-byte proxying across replicas, not the SPICE link
+// and auth wire handshake itself, which no test in this package drives.
+func TestMultiReplicaSessionReconnect(t *testing.T) {
+	redisAddr := fakeredis.Start(t)
+	tableA := redis.New(goredis.NewClient(&goredis.Options{Addr: redisAddr}), 0)
+	tableB := redis.New(goredis.NewClient(&goredis.Options{Addr: redisAddr}), 0)
+
+	proxyA, err := NewProxy(WithSessionTable(tableA))
+	if err != nil {
+		t.Fatalf("NewProxy (replica A): %v", err)
+	}
+	proxyB, err := NewProxy(WithSessionTable(tableB))
+	if err != nil {
+		t.Fatalf("NewProxy (replica B): %v", err)
+	}
+
+	destination := echoComputeListener(t)
+	const sessionID = uint32(777)
+	const otp = "secret-otp"
+
+	// Replica A completes the tenant's main channel.
+	mainCompute := dialCompute(t, proxyA, sessionID, destination)
+	tableA.Add(sessionID, destination, otp)
+
+	mainTenant, mainWire := net.Pipe()
+	go proxyStream(mainWire, mainCompute)
+	assertEcho(t, mainTenant, "hello from the main channel")
+	mainTenant.Close()
+
+	// Replica B handles a secondary channel reconnecting with the same
+	// sessionID: it must find the session replica A recorded.
+	if !tableB.Lookup(sessionID) {
+		t.Fatal("expected replica B to see the session replica A added")
+	}
+	gotDestination, err := tableB.Connect(sessionID)
+	if err != nil {
+		t.Fatalf("replica B Connect: %v", err)
+	}
+	if gotDestination != destination {
+		t.Fatalf("replica B destination = %q, want %q", gotDestination, destination)
+	}
+	if gotOTP := tableB.OTP(sessionID); gotOTP != otp {
+		t.Fatalf("replica B OTP = %q, want %q", gotOTP, otp)
+	}
+
+	secondaryCompute := dialCompute(t, proxyB, sessionID, gotDestination)
+	secondaryTenant, secondaryWire := net.Pipe()
+	go proxyStream(secondaryWire, secondaryCompute)
+	assertEcho(t, secondaryTenant, "hello from the cursor channel")
+	secondaryTenant.Close()
+
+	tableB.Delete(sessionID)
+	if tableA.Lookup(sessionID) {
+		t.Fatal("expected replica A to see the deletion made by replica B")
+	}
+}
+
+func assertEcho(t *testing.T, conn net.Conn, message string) {
+	t.Helper()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte(message)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	buf := make([]byte, len(message))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	if string(buf) != message {
+		t.Fatalf("echo = %q, want %q", buf, message)
+	}
+}
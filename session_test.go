@@ -0,0 +1,41 @@
+package spice
+
+import "testing"
+
+func TestMapSessionTable(t *testing.T) {
+	table := newMapSessionTable()
+
+	const sessionID = uint32(42)
+
+	if table.Lookup(sessionID) {
+		t.Fatal("expected no session before Add")
+	}
+
+	table.Add(sessionID, "10.0.0.1:5900", "secret-otp")
+
+	if !table.Lookup(sessionID) {
+		t.Fatal("expected session after Add")
+	}
+
+	dest, err := table.Connect(sessionID)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if dest != "10.0.0.1:5900" {
+		t.Fatalf("Connect destination = %q, want %q", dest, "10.0.0.1:5900")
+	}
+
+	if otp := table.OTP(sessionID); otp != "secret-otp" {
+		t.Fatalf("OTP = %q, want %q", otp, "secret-otp")
+	}
+
+	table.Delete(sessionID)
+
+	if table.Lookup(sessionID) {
+		t.Fatal("expected no session after Delete")
+	}
+
+	if _, err := table.Connect(sessionID); err == nil {
+		t.Fatal("expected error connecting to deleted session")
+	}
+}
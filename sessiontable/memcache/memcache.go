@@ -0,0 +1,89 @@
+// Package memcache implements spice.SessionTable on top of Memcached,
+// letting multiple proxy replicas behind a load balancer share session
+// state.
+package memcache
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+)
+
+// defaultTTL is how long an entry is kept before it expires if the
+// session is never reconnected or explicitly deleted.
+const defaultTTL = 2 * time.Minute
+
+const keyPrefix = "spice:session:"
+
+// SessionTable is a spice.SessionTable backed by a Memcached client.
+type SessionTable struct {
+	client *gomemcache.Client
+	ttl    time.Duration
+}
+
+type entry struct {
+	Destination string `json:"destination"`
+	OTP         string `json:"otp"`
+}
+
+// New creates a SessionTable using client, expiring entries after ttl. A
+// ttl of 0 uses the default of 2 minutes.
+func New(client *gomemcache.Client, ttl time.Duration) *SessionTable {
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return &SessionTable{client: client, ttl: ttl}
+}
+
+func (t *SessionTable) key(sessionID uint32) string {
+	return keyPrefix + strconv.FormatUint(uint64(sessionID), 10)
+}
+
+func (t *SessionTable) Add(sessionID uint32, destination, otp string) {
+	b, err := json.Marshal(entry{Destination: destination, OTP: otp})
+	if err != nil {
+		return
+	}
+	t.client.Set(&gomemcache.Item{
+		Key:        t.key(sessionID),
+		Value:      b,
+		Expiration: int32(t.ttl.Seconds()),
+	})
+}
+
+func (t *SessionTable) Lookup(sessionID uint32) bool {
+	_, err := t.client.Get(t.key(sessionID))
+	return err == nil
+}
+
+func (t *SessionTable) Connect(sessionID uint32) (string, error) {
+	e, err := t.get(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return e.Destination, nil
+}
+
+func (t *SessionTable) OTP(sessionID uint32) string {
+	e, err := t.get(sessionID)
+	if err != nil {
+		return ""
+	}
+	return e.OTP
+}
+
+func (t *SessionTable) Delete(sessionID uint32) {
+	t.client.Delete(t.key(sessionID))
+}
+
+func (t *SessionTable) get(sessionID uint32) (entry, error) {
+	var e entry
+	item, err := t.client.Get(t.key(sessionID))
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(item.Value, &e)
+	return e, err
+}
@@ -0,0 +1,195 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+)
+
+// startFakeMemcached runs a minimal memcached text-protocol server
+// supporting just the set/get/delete commands SessionTable needs, so
+// tests don't require a real memcached instance.
+func startFakeMemcached(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	store := &fakeMemcachedStore{values: make(map[string][]byte)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go store.serve(conn)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+type fakeMemcachedStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (s *fakeMemcachedStore) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(strings.TrimRight(line, "\r\n"))
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "set":
+			if len(fields) < 5 {
+				fmt.Fprint(conn, "CLIENT_ERROR bad command line format\r\n")
+				continue
+			}
+			n, err := strconv.Atoi(fields[4])
+			if err != nil {
+				fmt.Fprint(conn, "CLIENT_ERROR bad command line format\r\n")
+				continue
+			}
+			data := make([]byte, n)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.values[fields[1]] = data
+			s.mu.Unlock()
+			fmt.Fprint(conn, "STORED\r\n")
+
+		case "get", "gets":
+			if len(fields) < 2 {
+				fmt.Fprint(conn, "ERROR\r\n")
+				continue
+			}
+			s.mu.Lock()
+			data, ok := s.values[fields[1]]
+			s.mu.Unlock()
+			if ok {
+				if fields[0] == "gets" {
+					fmt.Fprintf(conn, "VALUE %s 0 %d 1\r\n", fields[1], len(data))
+				} else {
+					fmt.Fprintf(conn, "VALUE %s 0 %d\r\n", fields[1], len(data))
+				}
+				conn.Write(data)
+				fmt.Fprint(conn, "\r\n")
+			}
+			fmt.Fprint(conn, "END\r\n")
+
+		case "delete":
+			if len(fields) < 2 {
+				fmt.Fprint(conn, "ERROR\r\n")
+				continue
+			}
+			s.mu.Lock()
+			_, ok := s.values[fields[1]]
+			delete(s.values, fields[1])
+			s.mu.Unlock()
+			if ok {
+				fmt.Fprint(conn, "DELETED\r\n")
+			} else {
+				fmt.Fprint(conn, "NOT_FOUND\r\n")
+			}
+
+		default:
+			fmt.Fprint(conn, "ERROR\r\n")
+		}
+	}
+}
+
+func TestSessionTableRoundTrip(t *testing.T) {
+	addr := startFakeMemcached(t)
+	table := New(gomemcache.New(addr), 0)
+
+	const sessionID = uint32(42)
+
+	if table.Lookup(sessionID) {
+		t.Fatal("expected no session before Add")
+	}
+
+	table.Add(sessionID, "10.0.0.1:5900", "secret-otp")
+
+	if !table.Lookup(sessionID) {
+		t.Fatal("expected session after Add")
+	}
+
+	dest, err := table.Connect(sessionID)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if dest != "10.0.0.1:5900" {
+		t.Fatalf("Connect destination = %q, want %q", dest, "10.0.0.1:5900")
+	}
+
+	if otp := table.OTP(sessionID); otp != "secret-otp" {
+		t.Fatalf("OTP = %q, want %q", otp, "secret-otp")
+	}
+
+	table.Delete(sessionID)
+
+	if table.Lookup(sessionID) {
+		t.Fatal("expected no session after Delete")
+	}
+}
+
+// TestSessionTableSharedAcrossReplicas exercises the scenario that
+// motivates this package: a tenant's secondary channels (cursor, inputs,
+// display) may land on a different proxy replica than the main channel,
+// so the session added by one replica's SessionTable handle must be
+// visible through another handle talking to the same backend.
+func TestSessionTableSharedAcrossReplicas(t *testing.T) {
+	addr := startFakeMemcached(t)
+	replicaA := New(gomemcache.New(addr), 0)
+	replicaB := New(gomemcache.New(addr), 0)
+
+	const sessionID = uint32(99)
+
+	replicaA.Add(sessionID, "10.0.0.2:5900", "otp-xyz")
+
+	if !replicaB.Lookup(sessionID) {
+		t.Fatal("expected replica B to see the session added by replica A")
+	}
+
+	dest, err := replicaB.Connect(sessionID)
+	if err != nil {
+		t.Fatalf("replica B Connect: %v", err)
+	}
+	if dest != "10.0.0.2:5900" {
+		t.Fatalf("replica B destination = %q, want %q", dest, "10.0.0.2:5900")
+	}
+	if otp := replicaB.OTP(sessionID); otp != "otp-xyz" {
+		t.Fatalf("replica B OTP = %q, want %q", otp, "otp-xyz")
+	}
+
+	replicaB.Delete(sessionID)
+
+	if replicaA.Lookup(sessionID) {
+		t.Fatal("expected replica A to see the deletion made by replica B")
+	}
+}
@@ -0,0 +1,85 @@
+// Package redis implements spice.SessionTable on top of Redis, letting
+// multiple proxy replicas behind a load balancer share session state.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// defaultTTL is how long an entry is kept before it expires if the
+// session is never reconnected or explicitly deleted.
+const defaultTTL = 2 * time.Minute
+
+const keyPrefix = "spice:session:"
+
+// SessionTable is a spice.SessionTable backed by a Redis instance.
+type SessionTable struct {
+	client *goredis.Client
+	ttl    time.Duration
+}
+
+type entry struct {
+	Destination string `json:"destination"`
+	OTP         string `json:"otp"`
+}
+
+// New creates a SessionTable using client, expiring entries after ttl. A
+// ttl of 0 uses the default of 2 minutes.
+func New(client *goredis.Client, ttl time.Duration) *SessionTable {
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return &SessionTable{client: client, ttl: ttl}
+}
+
+func (t *SessionTable) key(sessionID uint32) string {
+	return keyPrefix + strconv.FormatUint(uint64(sessionID), 10)
+}
+
+func (t *SessionTable) Add(sessionID uint32, destination, otp string) {
+	b, err := json.Marshal(entry{Destination: destination, OTP: otp})
+	if err != nil {
+		return
+	}
+	t.client.Set(context.Background(), t.key(sessionID), b, t.ttl)
+}
+
+func (t *SessionTable) Lookup(sessionID uint32) bool {
+	n, err := t.client.Exists(context.Background(), t.key(sessionID)).Result()
+	return err == nil && n > 0
+}
+
+func (t *SessionTable) Connect(sessionID uint32) (string, error) {
+	e, err := t.get(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return e.Destination, nil
+}
+
+func (t *SessionTable) OTP(sessionID uint32) string {
+	e, err := t.get(sessionID)
+	if err != nil {
+		return ""
+	}
+	return e.OTP
+}
+
+func (t *SessionTable) Delete(sessionID uint32) {
+	t.client.Del(context.Background(), t.key(sessionID))
+}
+
+func (t *SessionTable) get(sessionID uint32) (entry, error) {
+	var e entry
+	b, err := t.client.Get(context.Background(), t.key(sessionID)).Bytes()
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(b, &e)
+	return e, err
+}
@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"testing"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/darkautism/go-spice/internal/fakeredis"
+)
+
+func TestSessionTableRoundTrip(t *testing.T) {
+	addr := fakeredis.Start(t)
+	table := New(goredis.NewClient(&goredis.Options{Addr: addr}), 0)
+
+	const sessionID = uint32(42)
+
+	if table.Lookup(sessionID) {
+		t.Fatal("expected no session before Add")
+	}
+
+	table.Add(sessionID, "10.0.0.1:5900", "secret-otp")
+
+	if !table.Lookup(sessionID) {
+		t.Fatal("expected session after Add")
+	}
+
+	dest, err := table.Connect(sessionID)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if dest != "10.0.0.1:5900" {
+		t.Fatalf("Connect destination = %q, want %q", dest, "10.0.0.1:5900")
+	}
+
+	if otp := table.OTP(sessionID); otp != "secret-otp" {
+		t.Fatalf("OTP = %q, want %q", otp, "secret-otp")
+	}
+
+	table.Delete(sessionID)
+
+	if table.Lookup(sessionID) {
+		t.Fatal("expected no session after Delete")
+	}
+}
+
+// TestSessionTableSharedAcrossReplicas exercises the scenario that
+// motivates this package: a tenant's secondary channels (cursor, inputs,
+// display) may land on a different proxy replica than the main channel,
+// so the session added by one replica's SessionTable handle must be
+// visible through another handle talking to the same backend.
+func TestSessionTableSharedAcrossReplicas(t *testing.T) {
+	addr := fakeredis.Start(t)
+	replicaA := New(goredis.NewClient(&goredis.Options{Addr: addr}), 0)
+	replicaB := New(goredis.NewClient(&goredis.Options{Addr: addr}), 0)
+
+	const sessionID = uint32(99)
+
+	replicaA.Add(sessionID, "10.0.0.2:5900", "otp-xyz")
+
+	if !replicaB.Lookup(sessionID) {
+		t.Fatal("expected replica B to see the session added by replica A")
+	}
+
+	dest, err := replicaB.Connect(sessionID)
+	if err != nil {
+		t.Fatalf("replica B Connect: %v", err)
+	}
+	if dest != "10.0.0.2:5900" {
+		t.Fatalf("replica B destination = %q, want %q", dest, "10.0.0.2:5900")
+	}
+	if otp := replicaB.OTP(sessionID); otp != "otp-xyz" {
+		t.Fatalf("replica B OTP = %q, want %q", otp, "otp-xyz")
+	}
+
+	replicaB.Delete(sessionID)
+
+	if replicaA.Lookup(sessionID) {
+		t.Fatal("expected replica A to see the deletion made by replica B")
+	}
+}
@@ -4,23 +4,35 @@ import (
 	"bufio"
 	"io"
 	"net"
-
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
+	"time"
 
 	"fmt"
 
 	"github.com/jsimonetti/go-spice/red"
 )
 
+// asHandshakeError maps a read deadline expiring mid-handshake to the
+// typed ErrHandshakeTimeout, so callers can distinguish it from an
+// ordinary disconnect.
+func asHandshakeError(err error) error {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return ErrHandshakeTimeout
+	}
+	return err
+}
+
 type tenantHandshake struct {
 	proxy *Proxy
 
+	// authenticator and sessionTable default to the proxy's own, but may
+	// be overridden per-connection by SNI-based vhost routing when the
+	// tenant connected over TLS.
+	authenticator map[red.AuthMethod]Authenticator
+	sessionTable  SessionTable
+
 	done bool
 
 	tenantAuthMethod red.AuthMethod
-	privateKey       *rsa.PrivateKey
 
 	channelID   uint8
 	channelType red.ChannelType
@@ -35,18 +47,32 @@ func (c *tenantHandshake) Done() bool {
 }
 
 func (c *tenantHandshake) clientLinkStage(tenant net.Conn) (net.Conn, error) {
+	if c.authenticator == nil {
+		c.authenticator = c.proxy.authenticator
+	}
+	if c.sessionTable == nil {
+		c.sessionTable = c.proxy.sessionTable
+	}
+
+	if c.proxy.handshakeTimeout > 0 {
+		if err := tenant.SetReadDeadline(time.Now().Add(c.proxy.handshakeTimeout)); err != nil {
+			return nil, err
+		}
+		defer tenant.SetReadDeadline(time.Time{})
+	}
+
 	bufConn := bufio.NewReader(tenant)
 
 	// Handle first Tenant Link Message
 	if err := c.clientLinkMessage(bufConn, tenant); err != nil {
-		return nil, err
+		return nil, asHandshakeError(err)
 	}
 
-	c.otp = c.proxy.sessionTable.OTP(c.sessionID)
+	c.otp = c.sessionTable.OTP(c.sessionID)
 
 	// Handle 2nd Tenant auth method select
 	if err := c.clientAuthMethod(bufConn, tenant); err != nil {
-		return nil, err
+		return nil, asHandshakeError(err)
 	}
 
 	// Do compute handshake
@@ -58,10 +84,10 @@ func (c *tenantHandshake) clientLinkStage(tenant net.Conn) (net.Conn, error) {
 		tenant:      tenant,
 	}
 
-	// Lookup destination in proxy.sessionTable
-	if c.proxy.sessionTable.Lookup(c.sessionID) {
+	// Lookup destination in the session table
+	if c.sessionTable.Lookup(c.sessionID) {
 		var err error
-		c.destination, err = c.proxy.sessionTable.Connect(c.sessionID)
+		c.destination, err = c.sessionTable.Connect(c.sessionID)
 		if err != nil {
 			return nil, err
 		}
@@ -75,17 +101,16 @@ func (c *tenantHandshake) clientLinkStage(tenant net.Conn) (net.Conn, error) {
 	}
 
 	c.sessionID = handShake.sessionID
-	c.proxy.sessionTable.Add(c.sessionID, c.destination, c.otp)
+	c.sessionTable.Add(c.sessionID, c.destination, c.otp)
 	c.done = true
 
 	return handShake.compute, nil
 }
 
 func (c *tenantHandshake) clientAuthMethod(in io.Reader, conn net.Conn) error {
-	var err error
 	b := make([]byte, 4)
 
-	if _, err = in.Read(b); err != nil {
+	if _, err := io.ReadFull(in, b); err != nil {
 		c.proxy.log.WithError(err).Error("error reading client AuthMethod")
 		return err
 	}
@@ -95,44 +120,64 @@ func (c *tenantHandshake) clientAuthMethod(in io.Reader, conn net.Conn) error {
 	var auth Authenticator
 	var ok bool
 
-	if auth, ok = c.proxy.authenticator[c.tenantAuthMethod]; !ok {
+	if auth, ok = c.authenticator[c.tenantAuthMethod]; !ok {
 		if err := sendServerTicket(red.ErrorPermissionDenied, conn); err != nil {
 			c.proxy.log.WithError(err).Warn("send ticket")
 		}
 		return fmt.Errorf("unavailable auth method %s", c.tenantAuthMethod)
 	}
 
-	authCtx := &AuthContext{tenant: conn, privateKey: c.privateKey, otp: c.otp, address: c.destination}
-
-	result, destination, err := auth.Next(authCtx)
-	if err != nil {
-		c.proxy.log.WithError(err).Error("authentication error")
-		return err
-	}
-
-	c.otp = authCtx.otp
-	c.destination = destination
+	authCtx := &AuthContext{tenant: conn, signer: c.proxy.ticketSigner, otp: c.otp, address: c.destination}
+	start := time.Now()
 
-	if !result {
-		if err := sendServerTicket(red.ErrorPermissionDenied, conn); err != nil {
-			c.proxy.log.WithError(err).Warn("send ticket")
+	for {
+		result, err := auth.Next(authCtx)
+		if err != nil {
+			c.proxy.log.WithError(err).Error("authentication error")
+			c.proxy.collector.AuthAttempt(c.tenantAuthMethod, false, time.Since(start))
 			return err
 		}
-		return fmt.Errorf("authentication failed")
-	}
 
-	if err := sendServerTicket(red.ErrorOk, conn); err != nil {
-		return err
+		switch r := result.(type) {
+		case AuthChallenge:
+			if err := writeAuthFrame(conn, r.Data); err != nil {
+				return err
+			}
+			resp, err := readAuthFrame(in, c.proxy.maxLinkMessageSize)
+			if err != nil {
+				c.proxy.log.WithError(err).Error("error reading auth response")
+				return err
+			}
+			authCtx.Response = resp
+
+		case AuthDone:
+			if r.Data != nil {
+				if err := writeAuthFrame(conn, r.Data); err != nil {
+					return err
+				}
+			}
+			c.otp = authCtx.otp
+			c.destination = r.Destination
+			c.proxy.collector.AuthAttempt(c.tenantAuthMethod, true, time.Since(start))
+			return sendServerTicket(red.ErrorOk, conn)
+
+		case AuthDeny:
+			c.proxy.collector.AuthAttempt(c.tenantAuthMethod, false, time.Since(start))
+			if err := sendServerTicket(r.Code, conn); err != nil {
+				c.proxy.log.WithError(err).Warn("send ticket")
+				return err
+			}
+			return fmt.Errorf("authentication denied: %s", r.Code)
+
+		default:
+			return fmt.Errorf("authenticator returned unknown result %T", result)
+		}
 	}
-
-	return nil
 }
 
 func (c *tenantHandshake) clientLinkMessage(in io.Reader, out io.Writer) error {
-	var err error
-	var b []byte
-
-	if b, err = readLinkPacket(in); err != nil {
+	b, err := newLinkReader(c.proxy.maxLinkMessageSize).read(in)
+	if err != nil {
 		c.proxy.log.WithError(err).Error("error reading link packet")
 		return err
 	}
@@ -153,6 +198,22 @@ func (c *tenantHandshake) clientLinkMessage(in io.Reader, out io.Writer) error {
 	return nil
 }
 
+// commonCapabilities builds the CommonCapabilities bitmask offered to the
+// tenant: the baseline bits plus any extra bits requested by registered
+// Authenticators that implement CapabilityProvider (e.g. the sasl
+// package's SPICE_COMMON_CAP_AUTH_SASL bit).
+func (c *tenantHandshake) commonCapabilities() uint32 {
+	caps := CapAuthSelection | CapAuthSpice | CapMiniHeader
+
+	for _, auth := range c.authenticator {
+		if cp, ok := auth.(CapabilityProvider); ok {
+			caps |= cp.CommonCapabilities()
+		}
+	}
+
+	return caps
+}
+
 func (c *tenantHandshake) sendServerLinkMessage(writer io.Writer) error {
 	pubkey, err := c.getPubkey()
 	if err != nil {
@@ -164,8 +225,8 @@ func (c *tenantHandshake) sendServerLinkMessage(writer io.Writer) error {
 		PubKey:              pubkey,
 		CommonCaps:          1,
 		ChannelCaps:         1,
-		CommonCapabilities:  []uint32{0x0b},
-		ChannelCapabilities: []uint32{0x09},
+		CommonCapabilities:  []red.Capability{red.Capability(c.commonCapabilities())},
+		ChannelCapabilities: []red.Capability{0x09},
 	}
 	b, err := reply.MarshalBinary()
 	if err != nil {
@@ -190,51 +251,8 @@ func (c *tenantHandshake) sendServerLinkMessage(writer io.Writer) error {
 	return nil
 }
 
-func (c *tenantHandshake) getPubkey() (ret [red.TicketPubkeyBytes]byte, err error) {
-	rng := rand.Reader
-	key, err := rsa.GenerateKey(rng, 1024)
-	if err != nil {
-		return ret, err
-	}
-	c.privateKey = key
-
-	cert, err := x509.MarshalPKIXPublicKey(key.Public())
-	if err != nil {
-		c.proxy.log.WithError(err).Error("rsa key parse error")
-		return ret, err
-	}
-
-	copy(ret[:], cert[:])
-	return ret, nil
-}
-
-func readLinkPacket(conn io.Reader) ([]byte, error) {
-	headerBytes := make([]byte, 16)
-
-	if _, err := conn.Read(headerBytes); err != nil {
-		return nil, err
-	}
-
-	header := &red.LinkHeader{}
-	if err := header.UnmarshalBinary(headerBytes); err != nil {
-		return nil, err
-	}
-
-	var messageBytes []byte
-	var n int
-	var err error
-	pending := int(header.Size)
-
-	for pending > 0 {
-		bytes := make([]byte, header.Size)
-		if n, err = conn.Read(bytes); err != nil {
-			return nil, err
-		}
-		pending = pending - n
-		messageBytes = append(messageBytes, bytes[:n]...)
-	}
-
-	return messageBytes[:int(header.Size)], nil
+func (c *tenantHandshake) getPubkey() ([red.TicketPubkeyBytes]byte, error) {
+	return c.proxy.ticketSigner.Public(), nil
 }
 
 func sendServerTicket(result red.ErrorCode, writer io.Writer) error {
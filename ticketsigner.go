@@ -0,0 +1,171 @@
+package spice
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+
+	"github.com/jsimonetti/go-spice/red"
+)
+
+// defaultSignerBits is the RSA key size used when a Proxy is not given an
+// explicit TicketSigner.
+const defaultSignerBits = 2048
+
+// maxAgentDecryptResponse bounds the plaintext length an agent may claim
+// in its Decrypt response, guarding against a misbehaving agent forcing
+// an arbitrary-size allocation.
+const maxAgentDecryptResponse = 1 << 20
+
+// TicketSigner decrypts the RSA-encrypted ticket a tenant sends during
+// authentication. It is modeled on ssh.Signer: implementations hold (or
+// have access to) the private key and never expose it directly, so the
+// key can live somewhere other than proxy memory, e.g. a file, an agent
+// process, or an HSM.
+type TicketSigner interface {
+	// Public returns the public key in the wire format SPICE expects in
+	// the ServerLinkMessage.
+	Public() [red.TicketPubkeyBytes]byte
+	// Decrypt decrypts a ciphertext produced by the tenant against the
+	// public key returned by Public.
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// rsaTicketSigner is an in-memory TicketSigner backed by an RSA private
+// key generated once, rather than per-connection.
+type rsaTicketSigner struct {
+	key *rsa.PrivateKey
+}
+
+// NewTicketSigner generates a new in-memory TicketSigner using an RSA key
+// of the given size in bits. Use 0 to get the default (2048).
+func NewTicketSigner(bits int) (TicketSigner, error) {
+	if bits == 0 {
+		bits = defaultSignerBits
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsaTicketSigner{key: key}, nil
+}
+
+// NewTicketSignerFromFile loads a PEM-encoded PKCS#1 or PKCS#8 RSA private
+// key from path to use as a TicketSigner.
+func NewTicketSignerFromFile(path string) (TicketSigner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errInvalidSignerFile
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err2 := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err2 != nil {
+			return nil, err
+		}
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errInvalidSignerFile
+		}
+		key = rsaKey
+	}
+
+	return &rsaTicketSigner{key: key}, nil
+}
+
+func (s *rsaTicketSigner) Public() (ret [red.TicketPubkeyBytes]byte) {
+	cert, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return ret
+	}
+	copy(ret[:], cert)
+	return ret
+}
+
+func (s *rsaTicketSigner) Decrypt(ciphertext []byte) ([]byte, error) {
+	return rsa.DecryptPKCS1v15(rand.Reader, s.key, ciphertext)
+}
+
+// agentTicketSigner forwards Decrypt calls to a separate process (or HSM
+// bridge) listening on a unix socket, so the private key never needs to
+// live in the proxy's own memory.
+type agentTicketSigner struct {
+	socketPath string
+	pubkey     [red.TicketPubkeyBytes]byte
+}
+
+// NewAgentTicketSigner dials the unix socket at socketPath once to fetch
+// the agent's public key, then returns a TicketSigner that forwards
+// subsequent Decrypt calls to it.
+func NewAgentTicketSigner(socketPath string) (TicketSigner, error) {
+	a := &agentTicketSigner{socketPath: socketPath}
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte{agentOpPublic}); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, a.pubkey[:]); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *agentTicketSigner) Public() [red.TicketPubkeyBytes]byte {
+	return a.pubkey
+}
+
+const (
+	agentOpPublic  byte = 0x01
+	agentOpDecrypt byte = 0x02
+)
+
+func (a *agentTicketSigner) Decrypt(ciphertext []byte) ([]byte, error) {
+	conn, err := net.Dial("unix", a.socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(ciphertext)))
+
+	if _, err := conn.Write(append([]byte{agentOpDecrypt}, append(length, ciphertext...)...)); err != nil {
+		return nil, err
+	}
+
+	respLen := make([]byte, 4)
+	if _, err := io.ReadFull(conn, respLen); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(respLen)
+	if size > maxAgentDecryptResponse {
+		return nil, errAgentResponseTooLarge
+	}
+
+	plaintext := make([]byte, size)
+	if _, err := io.ReadFull(conn, plaintext); err != nil {
+		return nil, err
+	}
+
+	return plaintext, nil
+}
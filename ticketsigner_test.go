@@ -0,0 +1,125 @@
+package spice
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRSATicketSignerRoundTrip(t *testing.T) {
+	signer, err := NewTicketSigner(0)
+	if err != nil {
+		t.Fatalf("NewTicketSigner: %v", err)
+	}
+
+	pub := signerPublicKey(t, signer)
+
+	want := []byte("one time password")
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, pub, want)
+	if err != nil {
+		t.Fatalf("EncryptPKCS1v15: %v", err)
+	}
+
+	got, err := signer.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("Decrypt = %q, want %q", got, want)
+	}
+}
+
+func TestTicketSignerFromFilePKCS1(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	path := writePEMKey(t, pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	signer, err := NewTicketSignerFromFile(path)
+	if err != nil {
+		t.Fatalf("NewTicketSignerFromFile: %v", err)
+	}
+
+	want := []byte("pkcs1 round trip")
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, want)
+	if err != nil {
+		t.Fatalf("EncryptPKCS1v15: %v", err)
+	}
+
+	got, err := signer.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("Decrypt = %q, want %q", got, want)
+	}
+}
+
+func TestTicketSignerFromFilePKCS8(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	path := writePEMKey(t, pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	signer, err := NewTicketSignerFromFile(path)
+	if err != nil {
+		t.Fatalf("NewTicketSignerFromFile: %v", err)
+	}
+
+	want := []byte("pkcs8 round trip")
+	ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, want)
+	if err != nil {
+		t.Fatalf("EncryptPKCS1v15: %v", err)
+	}
+
+	got, err := signer.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("Decrypt = %q, want %q", got, want)
+	}
+}
+
+func TestTicketSignerFromFileInvalid(t *testing.T) {
+	path := writePEMKey(t, pem.Block{Type: "PRIVATE KEY", Bytes: []byte("not a key")})
+
+	if _, err := NewTicketSignerFromFile(path); err == nil {
+		t.Fatal("expected error loading invalid key")
+	}
+}
+
+// signerPublicKey extracts the *rsa.PublicKey backing an in-memory
+// TicketSigner, for tests that need to encrypt a ciphertext to feed back
+// into Decrypt.
+func signerPublicKey(t *testing.T, signer TicketSigner) *rsa.PublicKey {
+	t.Helper()
+
+	s, ok := signer.(*rsaTicketSigner)
+	if !ok {
+		t.Fatalf("signer type = %T, want *rsaTicketSigner", signer)
+	}
+	return &s.key.PublicKey
+}
+
+func writePEMKey(t *testing.T, block pem.Block) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&block), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}